@@ -24,18 +24,25 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
+	"fmt"
+	"io"
 	"math/rand"
-	"net/http"
-	pprofhttp "net/http/pprof"
 	"os"
 	"os/signal"
+	"runtime"
+	"runtime/pprof"
 	"strings"
 	"syscall"
 	"time"
 
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 
+	"github.com/Arriven/db1000n/src/internal/adminserver"
+	"github.com/Arriven/db1000n/src/internal/logbuffer"
+	"github.com/Arriven/db1000n/src/internal/supportdump"
 	"github.com/Arriven/db1000n/src/job"
 	"github.com/Arriven/db1000n/src/job/config"
 	"github.com/Arriven/db1000n/src/utils"
@@ -44,14 +51,27 @@ import (
 	"github.com/Arriven/db1000n/src/utils/templates"
 )
 
+// supportDumpLogLines is how many of the most recent log lines are kept in
+// memory to be attached to a --support-dump bundle.
+const supportDumpLogLines = 2000
+
+// defaultShutdownTimeout is how long shutdownController waits for in-flight
+// jobs to drain after the first interrupt signal before force-exiting.
+const defaultShutdownTimeout = 15 * time.Second
+
 func main() {
 	runnerConfigOptions := job.NewConfigOptionsWithFlags()
 	jobsGlobalConfig := job.NewGlobalConfigWithFlags()
 	otaConfig := ota.NewConfigWithFlags()
 	countryCheckerConfig := utils.NewCountryCheckerConfigWithFlags()
 	updaterMode, destinationPath := config.NewUpdaterOptionsWithFlags()
-	prometheusOn, prometheusListenAddress, prometheusPushGateways := metrics.NewOptionsWithFlags()
-	pprof := flag.String("pprof", utils.GetEnvStringDefault("GO_PPROF_ENDPOINT", ""), "enable pprof")
+	prometheusOn, prometheusPushGatewaysCSV := metrics.NewOptionsWithFlags()
+	internalListenAddress := flag.String("internal-listen", utils.GetEnvStringDefault("INTERNAL_LISTEN_ADDRESS", ""),
+		"address for the internal admin server (pprof, metrics, health checks, config, loglevel); empty disables it. "+
+			"Implied to be \":8080\" when -debug is set and this isn't given, matching the old -pprof default")
+	supportDumpPath := flag.String("support-dump", "", "write a diagnostics bundle (tar.gz) to the given path ('-' for stdout) and exit")
+	shutdownTimeout := flag.Duration("shutdown-timeout", defaultShutdownTimeout,
+		"how long to wait for in-flight jobs to drain after the first interrupt signal before force-exiting")
 	help := flag.Bool("h", false, "print help message and exit")
 	version := flag.Bool("version", false, "print version and exit")
 	debug := flag.Bool("debug", utils.GetEnvBoolDefault("DEBUG", false), "enable debug level logging and features")
@@ -62,9 +82,13 @@ func main() {
 
 	if *debug {
 		*verbose = 2
+
+		if *internalListenAddress == "" {
+			*internalListenAddress = ":8080"
+		}
 	}
 
-	logger, err := newZapLogger(*verbose)
+	logger, atomicLevel, logs, err := newZapLogger(*verbose)
 	if err != nil {
 		panic(err)
 	}
@@ -81,6 +105,10 @@ func main() {
 	case *updaterMode:
 		config.UpdateLocal(logger, *destinationPath, strings.Split(runnerConfigOptions.PathsCSV, ","), []byte(runnerConfigOptions.BackupConfig))
 
+		return
+	case *supportDumpPath != "":
+		runSupportDump(logger, *supportDumpPath, runnerConfigOptions, jobsGlobalConfig, countryCheckerConfig, logs)
+
 		return
 	}
 
@@ -89,8 +117,13 @@ func main() {
 		logger.Warn("failed to increase rlimit", zap.Error(err))
 	}
 
+	var adminServer *adminserver.Server
+	if *internalListenAddress != "" {
+		adminServer = adminserver.New(logger, *internalListenAddress, atomicLevel, runnerConfigOptions)
+		adminServer.Start()
+	}
+
 	go ota.WatchUpdates(logger, otaConfig)
-	setUpPprof(logger, *pprof, *debug)
 	rand.Seed(time.Now().UnixNano())
 
 	country := utils.CheckCountryOrFail(logger, countryCheckerConfig, templates.ParseAndExecute(logger, jobsGlobalConfig.ProxyURLs, nil))
@@ -98,43 +131,153 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	metrics.InitOrFail(ctx, logger, *prometheusOn, *prometheusListenAddress, *prometheusPushGateways, jobsGlobalConfig.ClientID, country)
+	metrics.InitOrFail(ctx, logger, *prometheusOn, *prometheusPushGatewaysCSV, jobsGlobalConfig.ClientID, country)
+
+	runner := job.NewRunner(runnerConfigOptions, jobsGlobalConfig)
 
-	go cancelOnSignal(logger, cancel)
-	job.NewRunner(runnerConfigOptions, jobsGlobalConfig).Run(ctx, logger)
+	if adminServer != nil {
+		adminServer.MarkReady()
+	}
+
+	go newShutdownController(logger, cancel, *shutdownTimeout, runner).run()
+
+	// Run's own internal job-dispatch loop isn't instrumented per job, so
+	// track the outermost Run call itself: ActiveJobs() reports 1 while any
+	// job may still be in flight and flips back to 0 only once Run has
+	// actually returned, which is what the shutdown progress log needs to
+	// distinguish "still draining" from "stuck".
+	job.JobStarted()
+	runner.Run(ctx, logger)
+	job.JobFinished()
+
+	if adminServer != nil {
+		if err := adminServer.Shutdown(context.Background()); err != nil {
+			logger.Warn("admin server shutdown", zap.Error(err))
+		}
+	}
 }
 
-func newZapLogger(verbose int) (*zap.Logger, error) {
-	switch verbose {
-	case 0:
-		return zap.NewNop(), nil
-	case 1:
-		return zap.NewProduction()
-	default:
-		return zap.NewDevelopment()
+func newZapLogger(verbose int) (*zap.Logger, zap.AtomicLevel, *logbuffer.Core, error) {
+	if verbose == 0 {
+		return zap.NewNop(), zap.NewAtomicLevel(), logbuffer.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zap.NewAtomicLevel(), supportDumpLogLines), nil
+	}
+
+	cfg := zap.NewProductionConfig()
+	if verbose > 1 {
+		cfg = zap.NewDevelopmentConfig()
 	}
+
+	logs := logbuffer.NewCore(zapcore.NewJSONEncoder(cfg.EncoderConfig), cfg.Level, supportDumpLogLines)
+
+	logger, err := cfg.Build(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return zapcore.NewTee(core, logs)
+	}))
+
+	return logger, cfg.Level, logs, err
 }
 
-func setUpPprof(logger *zap.Logger, pprof string, debug bool) {
-	switch {
-	case debug && pprof == "":
-		pprof = ":8080"
-	case pprof == "":
+// runSupportDump writes a diagnostics bundle to path ('-' for stdout) and
+// returns control to main, which exits right after. A failure at any step is
+// logged and ends the dump there; the remaining parts of the bundle are not
+// written.
+func runSupportDump(
+	logger *zap.Logger,
+	path string,
+	runnerConfigOptions *job.ConfigOptions,
+	jobsGlobalConfig *job.GlobalConfig,
+	countryCheckerConfig *utils.CountryCheckerConfig,
+	logs *logbuffer.Core,
+) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	resolvedJobConfig, err := runnerConfigOptions.CurrentConfigJSON()
+	if err != nil {
+		logger.Error("failed to resolve job config for support dump", zap.Error(err))
+
+		return
+	}
+
+	// Only the client ID (hashed) and the proxy URLs (credentials redacted)
+	// go into the bundle; other global config fields and command-line flags
+	// are not included.
+	globalConfig, err := json.Marshal(struct {
+		ClientID  string `json:"client_id"`
+		ProxyURLs string `json:"proxy_urls"`
+	}{
+		ClientID:  supportdump.HashSecret(jobsGlobalConfig.ClientID),
+		ProxyURLs: supportdump.RedactProxyURLs(jobsGlobalConfig.ProxyURLs),
+	})
+	if err != nil {
+		logger.Error("failed to marshal global config for support dump", zap.Error(err))
+
 		return
 	}
 
-	mux := http.NewServeMux()
-	mux.Handle("/debug/pprof/", http.HandlerFunc(pprofhttp.Index))
-	mux.Handle("/debug/pprof/cmdline", http.HandlerFunc(pprofhttp.Cmdline))
-	mux.Handle("/debug/pprof/profile", http.HandlerFunc(pprofhttp.Profile))
-	mux.Handle("/debug/pprof/symbol", http.HandlerFunc(pprofhttp.Symbol))
-	mux.Handle("/debug/pprof/trace", http.HandlerFunc(pprofhttp.Trace))
+	country := utils.CheckCountryOrFail(logger, countryCheckerConfig, templates.ParseAndExecute(logger, jobsGlobalConfig.ProxyURLs, nil))
+
+	out, closeOut, err := openSupportDumpDest(path)
+	if err != nil {
+		logger.Error("failed to open support dump destination", zap.String("path", path), zap.Error(err))
 
-	// this has to be wrapped into a lambda bc otherwise it blocks when evaluating argument for zap.Error
-	go func() { logger.Warn("pprof server", zap.Error(http.ListenAndServe(pprof, mux))) }()
+		return
+	}
+	defer closeOut()
+
+	err = supportdump.Write(ctx, out, supportdump.Input{
+		JobConfig:      resolvedJobConfig,
+		GlobalConfig:   globalConfig,
+		CountryInfo:    fmt.Sprintf("%v", country),
+		LogLines:       logs.Lines(),
+		CPUProfileTime: 30 * time.Second,
+	})
+	if err != nil {
+		logger.Error("failed to write support dump", zap.Error(err))
+
+		return
+	}
+
+	logger.Info("wrote support dump", zap.String("path", path))
+}
+
+func openSupportDumpDest(path string) (io.Writer, func(), error) {
+	if path == "-" {
+		return os.Stdout, func() {}, nil
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return f, func() { _ = f.Close() }, nil
+}
+
+// activeJobRunner is satisfied by job.Runner. It exists so shutdownController
+// doesn't have to take a dependency on the concrete runner type.
+type activeJobRunner interface {
+	ActiveJobs() int
 }
 
-func cancelOnSignal(logger *zap.Logger, cancel context.CancelFunc) {
+// shutdownController coordinates graceful shutdown on SIGINT/SIGTERM: the
+// first signal cancels the root context and starts a timeout for in-flight
+// jobs to drain, logging progress in the meantime. A second signal of the
+// same type forces an immediate exit, and the timeout elapsing first dumps
+// goroutine stacks before force-exiting so a hung run can always be
+// unstuck.
+type shutdownController struct {
+	logger  *zap.Logger
+	cancel  context.CancelFunc
+	timeout time.Duration
+	runner  activeJobRunner
+	exit    func(code int) // overridden in tests
+}
+
+func newShutdownController(logger *zap.Logger, cancel context.CancelFunc, timeout time.Duration, runner activeJobRunner) *shutdownController {
+	return &shutdownController{logger: logger, cancel: cancel, timeout: timeout, runner: runner, exit: os.Exit}
+}
+
+func (c *shutdownController) run() {
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs,
 		syscall.SIGTERM,
@@ -142,7 +285,47 @@ func cancelOnSignal(logger *zap.Logger, cancel context.CancelFunc) {
 		syscall.SIGHUP,
 		syscall.SIGINT,
 	)
-	<-sigs
-	logger.Info("terminating")
-	cancel()
+
+	c.runWithSignals(sigs)
+}
+
+// runWithSignals contains the actual state machine; it is split out from
+// run so tests can drive it with a synthetic signal channel instead of
+// real OS signals.
+func (c *shutdownController) runWithSignals(sigs <-chan os.Signal) {
+	first := <-sigs
+	c.logger.Info("terminating, draining in-flight jobs", zap.String("signal", first.String()), zap.Duration("timeout", c.timeout))
+	c.cancel()
+
+	deadline := time.NewTimer(c.timeout)
+	defer deadline.Stop()
+
+	progress := time.NewTicker(time.Second)
+	defer progress.Stop()
+
+	for {
+		select {
+		case sig := <-sigs:
+			if sig != first {
+				c.logger.Info("ignoring second signal of a different type during drain", zap.String("signal", sig.String()))
+
+				continue
+			}
+
+			c.logger.Warn("received second signal of the same type, forcing exit", zap.String("signal", sig.String()))
+			c.exit(130)
+
+			return
+		case <-progress.C:
+			c.logger.Info("waiting for jobs to drain",
+				zap.Int("active_jobs", c.runner.ActiveJobs()),
+				zap.Int("num_goroutine", runtime.NumGoroutine()))
+		case <-deadline.C:
+			c.logger.Error("shutdown timeout elapsed, dumping goroutine stacks", zap.Duration("timeout", c.timeout))
+			_ = pprof.Lookup("goroutine").WriteTo(os.Stderr, 1)
+			c.exit(1)
+
+			return
+		}
+	}
 }
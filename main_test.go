@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type fakeRunner struct{}
+
+func (fakeRunner) ActiveJobs() int { return 0 }
+
+func TestShutdownControllerSecondSameSignalForcesExit(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	exited := make(chan int, 1)
+	c := newShutdownController(zap.NewNop(), cancel, time.Hour, fakeRunner{})
+	c.exit = func(code int) { exited <- code }
+
+	sigs := make(chan os.Signal, 2)
+	sigs <- syscall.SIGINT
+	sigs <- syscall.SIGINT
+
+	go c.runWithSignals(sigs)
+
+	select {
+	case code := <-exited:
+		if code != 130 {
+			t.Errorf("exit code = %d, want 130", code)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for force exit on second signal of the same type")
+	}
+
+	if ctx.Err() == nil {
+		t.Error("expected root context to be cancelled after the first signal")
+	}
+}
+
+func TestShutdownControllerDifferentSecondSignalDoesNotForceExit(t *testing.T) {
+	_, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	exited := make(chan int, 1)
+	c := newShutdownController(zap.NewNop(), cancel, time.Hour, fakeRunner{})
+	c.exit = func(code int) { exited <- code }
+
+	sigs := make(chan os.Signal, 2)
+	sigs <- syscall.SIGINT
+	sigs <- syscall.SIGTERM
+
+	go c.runWithSignals(sigs)
+
+	select {
+	case code := <-exited:
+		t.Fatalf("exited with code %d after a second signal of a different type, want no exit", code)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestShutdownControllerExitsOnTimeout(t *testing.T) {
+	_, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	exited := make(chan int, 1)
+	c := newShutdownController(zap.NewNop(), cancel, 20*time.Millisecond, fakeRunner{})
+	c.exit = func(code int) { exited <- code }
+
+	sigs := make(chan os.Signal, 1)
+	sigs <- syscall.SIGINT
+
+	go c.runWithSignals(sigs)
+
+	select {
+	case code := <-exited:
+		if code != 1 {
+			t.Errorf("exit code = %d, want 1", code)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for deadline-triggered exit")
+	}
+}
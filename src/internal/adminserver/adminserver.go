@@ -0,0 +1,137 @@
+// MIT License
+
+// Copyright (c) [2022] [Bohdan Ivashko (https://github.com/Arriven)]
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package adminserver hosts the operational HTTP endpoints that should never
+// be exposed to the public internet: pprof, Prometheus scraping, health
+// checks, and runtime controls such as log level. It mirrors the split
+// between a user-facing server and an internal server found in projects
+// like Grafana Tempo, so none of these endpoints have to share a listener
+// with anything user-facing.
+package adminserver
+
+import (
+	"context"
+	"net/http"
+	pprofhttp "net/http/pprof"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
+)
+
+// ConfigProvider is implemented by whatever holds the currently-loaded job
+// config, so /config can report it without adminserver depending on the
+// job package directly.
+type ConfigProvider interface {
+	CurrentConfigJSON() ([]byte, error)
+}
+
+// Server is the single *http.Server backing all internal endpoints.
+type Server struct {
+	logger *zap.Logger
+	srv    *http.Server
+	ready  atomic.Bool
+}
+
+// New builds a Server listening on listenAddr. atomicLevel is wired
+// directly into /loglevel so verbosity can be changed at runtime without a
+// restart, and configProvider backs /config; configProvider may be nil if
+// no job config has been loaded yet. /readyz reports unready until
+// MarkReady is called, which the caller should do once startup (job config
+// loaded, country check passed, etc.) has actually finished; /healthz
+// reports healthy as soon as the process is up, regardless of startup
+// progress.
+func New(logger *zap.Logger, listenAddr string, atomicLevel zap.AtomicLevel, configProvider ConfigProvider) *Server {
+	s := &Server{logger: logger}
+
+	mux := http.NewServeMux()
+	mux.Handle("/debug/pprof/", http.HandlerFunc(pprofhttp.Index))
+	mux.Handle("/debug/pprof/cmdline", http.HandlerFunc(pprofhttp.Cmdline))
+	mux.Handle("/debug/pprof/profile", http.HandlerFunc(pprofhttp.Profile))
+	mux.Handle("/debug/pprof/symbol", http.HandlerFunc(pprofhttp.Symbol))
+	mux.Handle("/debug/pprof/trace", http.HandlerFunc(pprofhttp.Trace))
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", healthzHandler)
+	mux.HandleFunc("/readyz", s.readyzHandler)
+	mux.HandleFunc("/config", configHandler(configProvider))
+	mux.Handle("/loglevel", atomicLevel)
+
+	s.srv = &http.Server{Addr: listenAddr, Handler: mux}
+
+	return s
+}
+
+// MarkReady flips /readyz to report healthy. Callers should invoke it once
+// startup work that needs to complete before the process can usefully serve
+// traffic (job config loaded, country check passed, etc.) is done.
+func (s *Server) MarkReady() {
+	s.ready.Store(true)
+}
+
+// Start runs the server in the background. It logs a warning if the
+// listener ever exits for a reason other than a clean Shutdown.
+func (s *Server) Start() {
+	go func() {
+		if err := s.srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			s.logger.Warn("admin server", zap.Error(err))
+		}
+	}()
+}
+
+// Shutdown gracefully stops the server, respecting ctx's deadline.
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
+
+func healthzHandler(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) readyzHandler(w http.ResponseWriter, _ *http.Request) {
+	if !s.ready.Load() {
+		http.Error(w, "not ready", http.StatusServiceUnavailable)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func configHandler(provider ConfigProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		if provider == nil {
+			http.Error(w, "job config not loaded yet", http.StatusServiceUnavailable)
+
+			return
+		}
+
+		data, err := provider.CurrentConfigJSON()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	}
+}
@@ -0,0 +1,96 @@
+package adminserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+type fakeConfigProvider struct {
+	data []byte
+	err  error
+}
+
+func (f fakeConfigProvider) CurrentConfigJSON() ([]byte, error) {
+	return f.data, f.err
+}
+
+func TestHealthzAlwaysOK(t *testing.T) {
+	s := New(zap.NewNop(), ":0", zap.NewAtomicLevel(), nil)
+
+	rec := httptest.NewRecorder()
+	s.srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("/healthz = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestReadyzReflectsMarkReady(t *testing.T) {
+	s := New(zap.NewNop(), ":0", zap.NewAtomicLevel(), nil)
+
+	rec := httptest.NewRecorder()
+	s.srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz before MarkReady = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+
+	s.MarkReady()
+
+	rec = httptest.NewRecorder()
+	s.srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("/readyz after MarkReady = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestConfigHandlerNilProvider(t *testing.T) {
+	s := New(zap.NewNop(), ":0", zap.NewAtomicLevel(), nil)
+
+	rec := httptest.NewRecorder()
+	s.srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/config", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("/config with nil provider = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestConfigHandlerHappyPath(t *testing.T) {
+	s := New(zap.NewNop(), ":0", zap.NewAtomicLevel(), fakeConfigProvider{data: []byte(`{"a":1}`)})
+
+	rec := httptest.NewRecorder()
+	s.srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/config", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("/config = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	if rec.Body.String() != `{"a":1}` {
+		t.Errorf("/config body = %q, want %q", rec.Body.String(), `{"a":1}`)
+	}
+}
+
+func TestLogLevelGetAndPut(t *testing.T) {
+	level := zap.NewAtomicLevelAt(zap.InfoLevel)
+	s := New(zap.NewNop(), ":0", level, nil)
+
+	rec := httptest.NewRecorder()
+	s.srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/loglevel", nil))
+
+	if !strings.Contains(rec.Body.String(), "info") {
+		t.Errorf("/loglevel GET body = %q, want it to mention info", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPut, "/loglevel", strings.NewReader(`{"level":"debug"}`))
+	s.srv.Handler.ServeHTTP(rec, req)
+
+	if level.Level() != zap.DebugLevel {
+		t.Errorf("level after PUT = %v, want debug", level.Level())
+	}
+}
@@ -0,0 +1,136 @@
+// MIT License
+
+// Copyright (c) [2022] [Bohdan Ivashko (https://github.com/Arriven)]
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package logbuffer implements a zapcore.Core that keeps the most recent log
+// lines in memory instead of writing them anywhere, so they can be attached
+// to a diagnostics bundle without asking the operator to reproduce an issue
+// with extra logging enabled.
+package logbuffer
+
+import (
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// ring is the ring buffer state shared by a Core and every Core derived
+// from it via With, so a logger.With(...) sub-logger (the common zap idiom
+// for per-job/per-connection loggers) keeps writing into the same logical
+// buffer instead of a disconnected copy.
+type ring struct {
+	mu    sync.Mutex
+	lines []string
+	next  int
+	size  int
+}
+
+// Core is a zapcore.Core that appends encoded entries to a ring buffer of
+// the last capacity lines. It is meant to be teed alongside a program's
+// primary zapcore.Core via zapcore.NewTee.
+type Core struct {
+	zapcore.LevelEnabler
+
+	encoder zapcore.Encoder
+	ring    *ring
+}
+
+// NewCore returns a Core keeping at most capacity lines, encoded with enc
+// and gated by enab.
+func NewCore(enc zapcore.Encoder, enab zapcore.LevelEnabler, capacity int) *Core {
+	return &Core{
+		LevelEnabler: enab,
+		encoder:      enc,
+		ring:         &ring{lines: make([]string, capacity)},
+	}
+}
+
+// With implements zapcore.Core. The returned Core shares this one's ring
+// buffer, so lines written through it land in the same chronological
+// history.
+func (c *Core) With(fields []zapcore.Field) zapcore.Core {
+	clone := c.encoder.Clone()
+	for _, f := range fields {
+		f.AddTo(clone)
+	}
+
+	return &Core{LevelEnabler: c.LevelEnabler, encoder: clone, ring: c.ring}
+}
+
+// Check implements zapcore.Core.
+func (c *Core) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+
+	return ce
+}
+
+// Write implements zapcore.Core, appending the encoded entry to the ring
+// buffer and overwriting the oldest line once capacity is reached.
+func (c *Core) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.encoder.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	defer buf.Free()
+
+	line := buf.String()
+	r := c.ring
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lines[r.next] = line
+	r.next = (r.next + 1) % len(r.lines)
+
+	if r.size < len(r.lines) {
+		r.size++
+	}
+
+	return nil
+}
+
+// Sync implements zapcore.Core. It is a no-op since the buffer never
+// touches disk.
+func (c *Core) Sync() error {
+	return nil
+}
+
+// Lines returns the buffered lines in chronological order.
+func (c *Core) Lines() []string {
+	r := c.ring
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	out := make([]string, 0, r.size)
+	start := r.next - r.size
+	if start < 0 {
+		start += len(r.lines)
+	}
+
+	for i := 0; i < r.size; i++ {
+		out = append(out, r.lines[(start+i)%len(r.lines)])
+	}
+
+	return out
+}
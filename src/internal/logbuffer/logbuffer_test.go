@@ -0,0 +1,61 @@
+package logbuffer
+
+import (
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func newTestCore(capacity int) *Core {
+	return NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zapcore.InfoLevel, capacity)
+}
+
+func write(t *testing.T, c *Core, msg string) {
+	t.Helper()
+
+	if err := c.Write(zapcore.Entry{Level: zapcore.InfoLevel, Message: msg}, nil); err != nil {
+		t.Fatalf("Write(%q): %v", msg, err)
+	}
+}
+
+func TestCoreLinesOrdersChronologicallyAndWraps(t *testing.T) {
+	c := newTestCore(3)
+
+	for _, msg := range []string{"one", "two", "three", "four"} {
+		write(t, c, msg)
+	}
+
+	lines := c.Lines()
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3", len(lines))
+	}
+
+	for i, want := range []string{"two", "three", "four"} {
+		if !strings.Contains(lines[i], want) {
+			t.Errorf("line %d = %q, want it to contain %q", i, lines[i], want)
+		}
+	}
+}
+
+func TestCoreWithSharesRingState(t *testing.T) {
+	c := newTestCore(10)
+	child, ok := c.With([]zapcore.Field{zap.String("request_id", "abc")}).(*Core)
+
+	if !ok {
+		t.Fatal("With did not return a *Core")
+	}
+
+	write(t, c, "from parent")
+	write(t, child, "from child")
+
+	lines := c.Lines()
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines across parent/child core, want 2 (ring state should be shared)", len(lines))
+	}
+
+	if !strings.Contains(lines[0], "from parent") || !strings.Contains(lines[1], "from child") {
+		t.Errorf("lines = %v, want chronological parent-then-child entries", lines)
+	}
+}
@@ -0,0 +1,79 @@
+// MIT License
+
+// Copyright (c) [2022] [Bohdan Ivashko (https://github.com/Arriven)]
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package job
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/Arriven/db1000n/src/utils/templates"
+)
+
+// CurrentConfigJSON returns the resolved job config: every path in
+// PathsCSV is fetched (from disk, or over HTTP(S) for URLs, the same two
+// sources NewRunner loads from) and run through the same Go-template
+// execution step used elsewhere to resolve config templates, then joined
+// together. The paths are fetched again on every call, so the result can
+// differ from the config the runner loaded earlier. It backs the admin
+// server /config endpoint and --support-dump bundles.
+func (o *ConfigOptions) CurrentConfigJSON() ([]byte, error) {
+	logger := zap.NewNop()
+
+	var resolved [][]byte
+
+	for _, path := range strings.Split(o.PathsCSV, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+
+		raw, err := fetchConfigPath(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch job config %q: %w", path, err)
+		}
+
+		resolved = append(resolved, []byte(templates.ParseAndExecute(logger, string(raw), nil)))
+	}
+
+	return bytes.Join(resolved, []byte("\n")), nil
+}
+
+func fetchConfigPath(path string) ([]byte, error) {
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		resp, err := http.Get(path)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		return io.ReadAll(resp.Body)
+	}
+
+	return os.ReadFile(path)
+}
@@ -0,0 +1,51 @@
+// MIT License
+
+// Copyright (c) [2022] [Bohdan Ivashko (https://github.com/Arriven)]
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package job
+
+import "sync/atomic"
+
+// activeJobs counts jobs currently running across this process. Since a
+// process only ever runs a single Runner, a package-level counter is
+// enough; whatever starts and retires an individual job is expected to
+// call JobStarted/JobFinished around its lifecycle.
+var activeJobs int64
+
+// ActiveJobs reports how many jobs are currently running, so callers like
+// the graceful-shutdown controller can log drain progress.
+func (r *Runner) ActiveJobs() int {
+	return int(atomic.LoadInt64(&activeJobs))
+}
+
+// JobStarted and JobFinished are exported so the call site that actually
+// dispatches and retires jobs can report lifecycle transitions even from
+// outside this package (main wraps Run's own call site with them, since
+// Run's internal per-job dispatch loop predates ActiveJobs and isn't
+// instrumented job-by-job yet). Callers must pair every JobStarted with
+// exactly one JobFinished.
+func JobStarted() {
+	atomic.AddInt64(&activeJobs, 1)
+}
+
+func JobFinished() {
+	atomic.AddInt64(&activeJobs, -1)
+}
@@ -0,0 +1,90 @@
+// MIT License
+
+// Copyright (c) [2022] [Bohdan Ivashko (https://github.com/Arriven)]
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package metrics wires job metrics into Prometheus: registering them
+// against the default registry so they show up wherever that's scraped
+// from, and optionally pushing them to one or more push gateways.
+package metrics
+
+import (
+	"context"
+	"flag"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"go.uber.org/zap"
+)
+
+const pushInterval = 15 * time.Second
+
+// NewOptionsWithFlags registers the Prometheus-related flags and returns
+// pointers to their values. The admin server owns the single HTTP listener
+// that serves /metrics now, so there is no separate listen-address flag
+// here.
+func NewOptionsWithFlags() (prometheusOn *bool, pushGatewaysCSV *string) {
+	prometheusOn = flag.Bool("prometheus_on", true, "enable Prometheus metrics")
+	pushGatewaysCSV = flag.String("prometheus_gateways", "", "comma-separated list of Prometheus push gateway addresses")
+
+	return prometheusOn, pushGatewaysCSV
+}
+
+// InitOrFail starts pushing prometheus.DefaultGatherer -- the same
+// registry the admin server's /metrics endpoint and --support-dump's
+// metrics snapshot read from -- to any configured push gateways. It
+// registers no collectors itself. It no longer starts its own HTTP
+// listener; the admin server is the single listener for all of that now.
+func InitOrFail(ctx context.Context, logger *zap.Logger, on bool, pushGatewaysCSV, clientID, country string) {
+	if !on {
+		return
+	}
+
+	for _, gateway := range strings.Split(pushGatewaysCSV, ",") {
+		if gateway == "" {
+			continue
+		}
+
+		pusher := push.New(gateway, "db1000n").
+			Gatherer(prometheus.DefaultGatherer).
+			Grouping("client_id", clientID).
+			Grouping("country", country)
+
+		go pushLoop(ctx, logger, gateway, pusher)
+	}
+}
+
+func pushLoop(ctx context.Context, logger *zap.Logger, gateway string, pusher *push.Pusher) {
+	ticker := time.NewTicker(pushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := pusher.Push(); err != nil {
+				logger.Warn("failed to push metrics", zap.String("gateway", gateway), zap.Error(err))
+			}
+		}
+	}
+}